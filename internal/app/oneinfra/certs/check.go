@@ -0,0 +1,37 @@
+/*
+Copyright 2020 Rafael Fernández López <ereslibre@ereslibre.es>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certs
+
+import (
+	"fmt"
+	"time"
+
+	"oneinfra.ereslibre.es/m/internal/pkg/cluster"
+)
+
+// Check prints the renewal status of every certificate issued so far
+// for cluster clusterName, analogous to `kubeadm certs check-expiration`
+func Check(clusterName string) error {
+	loadedCluster, err := cluster.LoadCluster(clusterName)
+	if err != nil {
+		return err
+	}
+	for _, status := range loadedCluster.CertificateStatuses() {
+		fmt.Printf("%s\texpires %s\t(%s)\n", status.Name, status.NotAfter.Format(time.RFC3339), time.Until(status.NotAfter).Round(time.Hour))
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2020 Rafael Fernández López <ereslibre@ereslibre.es>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certs
+
+import (
+	"oneinfra.ereslibre.es/m/internal/pkg/cluster"
+)
+
+// RotateCA stages certificate and privateKey as the next CA for the
+// certificate authority named caName on cluster clusterName, so it is
+// included in the trust bundle ahead of the rotation being completed
+// with CompleteCARotation
+func RotateCA(clusterName, caName, certificate, privateKey string) error {
+	loadedCluster, err := cluster.LoadCluster(clusterName)
+	if err != nil {
+		return err
+	}
+	certificateAuthority, err := loadedCluster.CertificateAuthority(caName)
+	if err != nil {
+		return err
+	}
+	certificateAuthority.BeginRotation(certificate, privateKey)
+	return loadedCluster.Save()
+}
+
+// CompleteCARotation flips the certificate authority named caName on
+// cluster clusterName over to the CA staged with RotateCA
+func CompleteCARotation(clusterName, caName string) error {
+	loadedCluster, err := cluster.LoadCluster(clusterName)
+	if err != nil {
+		return err
+	}
+	certificateAuthority, err := loadedCluster.CertificateAuthority(caName)
+	if err != nil {
+		return err
+	}
+	if err := certificateAuthority.CompleteRotation(); err != nil {
+		return err
+	}
+	return loadedCluster.Save()
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2020 Rafael Fernández López <ereslibre@ereslibre.es>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"time"
+)
+
+// defaultCertificateValidity is how long a leaf certificate issued by
+// CreateCertificate is valid for
+const defaultCertificateValidity = 365 * 24 * time.Hour
+
+// CertificateAuthority represents a certificate authority able to issue
+// leaf certificates signed by it
+type CertificateAuthority struct {
+	Certificate string
+	PrivateKey  string
+	// NextCertificate, when set, is a new CA certificate staged ahead of
+	// a CA rotation. It is included in the trust bundle handed out to
+	// clients before the serving certificates are re-signed with it, so
+	// that the rotation itself is non-disruptive
+	NextCertificate string
+	// NextPrivateKey is the private key matching NextCertificate, kept
+	// unused for issuing leaf certificates until CompleteRotation flips
+	// it into place
+	NextPrivateKey string
+}
+
+// BeginRotation stages certificate and privateKey as this certificate
+// authority's next CA, to be included in TrustBundle until
+// CompleteRotation flips the CA over to it
+func (ca *CertificateAuthority) BeginRotation(certificate, privateKey string) {
+	ca.NextCertificate = certificate
+	ca.NextPrivateKey = privateKey
+}
+
+// CompleteRotation flips a staged CA rotation into place, making the
+// staged certificate and key the ones used to issue new leaf
+// certificates, and clears the staged fields. It fails if no rotation
+// was staged with BeginRotation
+func (ca *CertificateAuthority) CompleteRotation() error {
+	if ca.NextCertificate == "" {
+		return errors.New("no certificate authority rotation staged")
+	}
+	ca.Certificate = ca.NextCertificate
+	ca.PrivateKey = ca.NextPrivateKey
+	ca.NextCertificate = ""
+	ca.NextPrivateKey = ""
+	return nil
+}
+
+// CreateCertificate issues a new certificate signed by this certificate
+// authority, for the given common name, DNS names and organizations
+func (ca *CertificateAuthority) CreateCertificate(commonName string, dnsNames, organizations []string) (certificate, privateKey string, err error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", "", err
+	}
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return "", "", err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			CommonName:   commonName,
+			Organization: organizations,
+		},
+		DNSNames:              dnsNames,
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(defaultCertificateValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	derBytes, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return "", "", err
+	}
+	certificatePEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: derBytes}))
+	privateKeyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	return certificatePEM, privateKeyPEM, nil
+}
+
+// TrustBundle returns the PEM bundle that should be distributed as a
+// client-ca-file: the current CA certificate, plus the staged next CA
+// certificate while a CA rotation is in progress
+func (ca *CertificateAuthority) TrustBundle() string {
+	if ca.NextCertificate == "" {
+		return ca.Certificate
+	}
+	return ca.Certificate + ca.NextCertificate
+}
+
+// certificateNotAfter returns the expiration time encoded in a PEM
+// encoded certificate
+func certificateNotAfter(certificatePEM string) (time.Time, error) {
+	block, _ := pem.Decode([]byte(certificatePEM))
+	if block == nil {
+		return time.Time{}, errors.New("could not decode certificate PEM")
+	}
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return certificate.NotAfter, nil
+}
@@ -0,0 +1,291 @@
+/*
+Copyright 2020 Rafael Fernández López <ereslibre@ereslibre.es>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// clusterStateDir is where a cluster's reconciled state (including
+// issued certificates) is persisted between reconciliations, so it can
+// later be retrieved with LoadCluster
+const clusterStateDir = "/var/lib/oneinfra/clusters"
+
+func clusterStatePath(name string) string {
+	return filepath.Join(clusterStateDir, name+".json")
+}
+
+// Cluster represents a Kubernetes cluster managed by oneinfra
+type Cluster struct {
+	// Name is the unique name of this cluster
+	Name string
+	// KubernetesVersion is the Kubernetes version that will be used
+	// to build the control plane component images; when empty, the
+	// oneinfra default version is used
+	KubernetesVersion string
+	// ImageRepository is the image repository control plane component
+	// images will be pulled from; when empty, the oneinfra default
+	// repository is used. This allows pulling from a mirror such as
+	// registry.k8s.io, or from an air-gapped registry
+	ImageRepository string
+
+	CertificateAuthorities *CertificateAuthorities
+	EtcdServer             *EtcdServer
+	APIServer              *APIServer
+	ControllerManager      *ControllerManager
+	Scheduler              *Scheduler
+
+	// ExternalEtcd, when set, points the control plane at an etcd
+	// cluster managed outside oneinfra, instead of running a co-located
+	// etcd instance per apiserver
+	ExternalEtcd *ExternalEtcd
+
+	// EnabledComponents lists the optional control plane components to
+	// reconcile for this cluster, on top of the core control plane
+	// (etcd, apiserver, controller-manager, scheduler), which is always
+	// enabled. Valid values are "konnectivity", "cloud-controller-manager"
+	// and "kube-proxy"
+	EnabledComponents []string
+	// CloudProvider is the cloud provider name passed to
+	// cloud-controller-manager when the "cloud-controller-manager"
+	// component is enabled
+	CloudProvider string
+
+	// IssuedCertificates indexes every control plane certificate issued
+	// so far for this cluster by name (e.g.
+	// "apiserver-etcd-client-<component>"), so reconciliation can renew
+	// them instead of reissuing them on every run
+	IssuedCertificates map[string]*IssuedCertificate
+}
+
+// IssuedCertificate tracks a certificate that has already been issued
+// for this cluster, along with its expiration, so it can be renewed
+// instead of unconditionally reissued on every reconciliation
+type IssuedCertificate struct {
+	Certificate string
+	PrivateKey  string
+	NotAfter    time.Time
+}
+
+// CertificateStatus summarizes the renewal status of a single issued
+// certificate, as surfaced by the `oneinfra certs check` CLI subcommand
+type CertificateStatus struct {
+	Name     string
+	NotAfter time.Time
+}
+
+// CertificateStatuses returns the renewal status of every certificate
+// issued so far for this cluster
+func (cluster *Cluster) CertificateStatuses() []CertificateStatus {
+	statuses := make([]CertificateStatus, 0, len(cluster.IssuedCertificates))
+	for name, issued := range cluster.IssuedCertificates {
+		statuses = append(statuses, CertificateStatus{Name: name, NotAfter: issued.NotAfter})
+	}
+	return statuses
+}
+
+// RecordIssuedCertificate records a newly issued certificate for name,
+// computing and storing its expiration so it can later be checked for
+// renewal
+func (cluster *Cluster) RecordIssuedCertificate(name, certificate, privateKey string) error {
+	notAfter, err := certificateNotAfter(certificate)
+	if err != nil {
+		return err
+	}
+	if cluster.IssuedCertificates == nil {
+		cluster.IssuedCertificates = map[string]*IssuedCertificate{}
+	}
+	cluster.IssuedCertificates[name] = &IssuedCertificate{
+		Certificate: certificate,
+		PrivateKey:  privateKey,
+		NotAfter:    notAfter,
+	}
+	return nil
+}
+
+// ExternalEtcd describes an etcd cluster managed outside oneinfra that
+// the control plane should use instead of a co-located etcd instance;
+// it mirrors kubeadm's Etcd.External configuration
+type ExternalEtcd struct {
+	// Endpoints are the etcd client URLs of the external etcd cluster
+	Endpoints []string
+	// CAFile is the contents of the CA certificate trusted to validate
+	// the external etcd cluster's serving certificates
+	CAFile string
+	// CertFile is the contents of the client certificate used to
+	// authenticate against the external etcd cluster
+	CertFile string
+	// KeyFile is the contents of the private key matching CertFile
+	KeyFile string
+}
+
+// CertificateAuthorities groups all certificate authorities this cluster
+// relies on to issue control plane certificates
+type CertificateAuthorities struct {
+	APIServerClient *CertificateAuthority
+	EtcdClient      *CertificateAuthority
+}
+
+// EtcdServer holds the etcd server certificate authority for this cluster
+type EtcdServer struct {
+	CA *CertificateAuthority
+}
+
+// APIServer holds the kube-apiserver material for this cluster
+type APIServer struct {
+	TLSCert                  string
+	TLSPrivateKey            string
+	ServiceAccountPublicKey  string
+	ServiceAccountPrivateKey string
+
+	// ExtraArgs are merged on top of the oneinfra default kube-apiserver
+	// arguments; on conflict, the value provided here wins
+	ExtraArgs map[string]string
+	// AuthorizationMode overrides the default Node,RBAC authorization
+	// mode chain when non-empty
+	AuthorizationMode []string
+	// EnableAdmissionPlugins is appended to the default set of enabled
+	// admission plugins
+	EnableAdmissionPlugins []string
+	// FeatureGates is merged on top of the oneinfra default
+	// kube-apiserver feature gates
+	FeatureGates map[string]bool
+
+	// AuditPolicy, when set, is the contents of an audit policy file
+	// uploaded to the secrets path and referenced via
+	// --audit-policy-file; audit logs are written to AuditLogPath
+	AuditPolicy string
+	// AuditLogPath is where kube-apiserver writes its audit log to; it
+	// defaults to a path under the component's secrets directory when
+	// AuditPolicy is set and this is left empty
+	AuditLogPath string
+	// AuditLogMaxAge is the --audit-log-maxage value, in days; it is
+	// only applied when AuditPolicy is set
+	AuditLogMaxAge int
+	// AuditLogMaxBackups is the --audit-log-maxbackup value; it is only
+	// applied when AuditPolicy is set
+	AuditLogMaxBackups int
+
+	// EncryptionConfig, when set, is uploaded to the secrets path and
+	// referenced via --encryption-provider-config, enabling encryption
+	// at rest for the resources it lists
+	EncryptionConfig *EncryptionConfig
+}
+
+// EncryptionConfig describes the apiserver's encryption-at-rest
+// configuration, as rendered into an EncryptionConfiguration manifest
+type EncryptionConfig struct {
+	// Resources is the list of resources to encrypt (e.g. "secrets")
+	Resources []string
+	// Providers is the ordered list of encryption providers to use for
+	// the listed resources; the first provider is used to encrypt, all
+	// are tried in order to decrypt
+	Providers []EncryptionProvider
+}
+
+// EncryptionProvider represents a single encryption-at-rest provider:
+// aescbc, kms or secretbox
+type EncryptionProvider struct {
+	// Type is one of "aescbc", "kms" or "secretbox"
+	Type string
+	// Name identifies this provider instance (required for "kms")
+	Name string
+	// Keys are the base64 encoded keys used by "aescbc" and "secretbox"
+	Keys []string
+	// Endpoint is the KMS plugin gRPC unix socket endpoint (required
+	// for "kms")
+	Endpoint string
+	// Image is the KMS plugin sidecar container image (required for
+	// "kms")
+	Image string
+}
+
+// ControllerManager holds the kube-controller-manager configuration for
+// this cluster
+type ControllerManager struct {
+	// ExtraArgs are merged on top of the oneinfra default
+	// kube-controller-manager arguments; on conflict, the value
+	// provided here wins
+	ExtraArgs map[string]string
+	// FeatureGates is merged on top of the oneinfra default
+	// kube-controller-manager feature gates
+	FeatureGates map[string]bool
+}
+
+// Scheduler holds the kube-scheduler configuration for this cluster
+type Scheduler struct {
+	// ExtraArgs are merged on top of the oneinfra default kube-scheduler
+	// arguments; on conflict, the value provided here wins
+	ExtraArgs map[string]string
+	// FeatureGates is merged on top of the oneinfra default
+	// kube-scheduler feature gates
+	FeatureGates map[string]bool
+}
+
+// KubeConfig returns a kubeconfig pointing at the given endpoint,
+// authenticated against this cluster
+func (cluster *Cluster) KubeConfig(endpoint string) (string, error) {
+	return "", nil
+}
+
+// Save persists this cluster's state to local disk, so it can later be
+// retrieved with LoadCluster. ReconcileAll calls this after every
+// successful reconciliation
+func (cluster *Cluster) Save() error {
+	data, err := json.MarshalIndent(cluster, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(clusterStateDir, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(clusterStatePath(cluster.Name), data, 0600)
+}
+
+// LoadCluster loads a previously reconciled cluster by name, so that
+// commands such as `oneinfra certs check` can operate on its state
+func LoadCluster(name string) (*Cluster, error) {
+	data, err := os.ReadFile(clusterStatePath(name))
+	if err != nil {
+		return nil, fmt.Errorf("could not load cluster %q: %w", name, err)
+	}
+	var loadedCluster Cluster
+	if err := json.Unmarshal(data, &loadedCluster); err != nil {
+		return nil, err
+	}
+	return &loadedCluster, nil
+}
+
+// CertificateAuthority returns the certificate authority identified by
+// name ("apiserver-client", "etcd-client" or "etcd-server"), so CA
+// rotation can be driven by name from the CLI
+func (cluster *Cluster) CertificateAuthority(name string) (*CertificateAuthority, error) {
+	switch name {
+	case "apiserver-client":
+		return cluster.CertificateAuthorities.APIServerClient, nil
+	case "etcd-client":
+		return cluster.CertificateAuthorities.EtcdClient, nil
+	case "etcd-server":
+		return cluster.EtcdServer.CA, nil
+	default:
+		return nil, fmt.Errorf("unknown certificate authority %q", name)
+	}
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2020 Rafael Fernández López <ereslibre@ereslibre.es>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package component
+
+import (
+	"oneinfra.ereslibre.es/m/internal/pkg/infra"
+)
+
+// Component represents a single instance of a cluster component (e.g. a
+// control plane replica), placed on a given hypervisor
+type Component struct {
+	Name string
+	// AllocatedHostPorts maps a named port (e.g. "apiserver",
+	// "etcd-client") to the host port it has been allocated on its
+	// hypervisor
+	AllocatedHostPorts map[string]int
+}
+
+// RequestPort requests a host port for the given named port on the
+// provided hypervisor, allocating one if it has not been allocated yet
+func (component *Component) RequestPort(hypervisor *infra.Hypervisor, name string) (int, error) {
+	if hostPort, ok := component.AllocatedHostPorts[name]; ok {
+		return hostPort, nil
+	}
+	hostPort, err := hypervisor.AllocatePort()
+	if err != nil {
+		return 0, err
+	}
+	if component.AllocatedHostPorts == nil {
+		component.AllocatedHostPorts = map[string]int{}
+	}
+	component.AllocatedHostPorts[name] = hostPort
+	return hostPort, nil
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2020 Rafael Fernández López <ereslibre@ereslibre.es>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// argsBuilder accumulates oneinfra default arguments for a control plane
+// component, allowing user-supplied extra arguments to be merged on top
+// of them; on conflict, the user-supplied value always wins
+type argsBuilder struct {
+	args map[string]string
+}
+
+func newArgsBuilder() *argsBuilder {
+	return &argsBuilder{args: map[string]string{}}
+}
+
+// set registers a oneinfra default argument; it is a no-op if the
+// argument has already been overridden by the user
+func (builder *argsBuilder) set(name, value string) *argsBuilder {
+	if _, exists := builder.args[name]; !exists {
+		builder.args[name] = value
+	}
+	return builder
+}
+
+// merge overlays user-supplied extra arguments on top of the defaults
+// registered so far, with the user-supplied value winning on conflict
+func (builder *argsBuilder) merge(extraArgs map[string]string) *argsBuilder {
+	for name, value := range extraArgs {
+		builder.args[name] = value
+	}
+	return builder
+}
+
+// mergeFeatureGates renders the given feature gates map as a single
+// "key=value,..." --feature-gates argument, merged on top of any
+// oneinfra default feature gates already set
+func (builder *argsBuilder) mergeFeatureGates(featureGates map[string]bool) *argsBuilder {
+	if len(featureGates) == 0 {
+		return builder
+	}
+	existing := map[string]bool{}
+	if current, ok := builder.args["--feature-gates"]; ok {
+		for _, pair := range strings.Split(current, ",") {
+			if pair == "" {
+				continue
+			}
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) == 2 {
+				existing[kv[0]] = kv[1] == "true"
+			}
+		}
+	}
+	for name, enabled := range featureGates {
+		existing[name] = enabled
+	}
+	names := make([]string, 0, len(existing))
+	for name := range existing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+"="+strconv.FormatBool(existing[name]))
+	}
+	builder.args["--feature-gates"] = strings.Join(pairs, ",")
+	return builder
+}
+
+// build renders the accumulated arguments as a flat --flag value list,
+// suitable for a pod.Container Args field
+func (builder *argsBuilder) build() []string {
+	names := make([]string, 0, len(builder.args))
+	for name := range builder.args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := make([]string, 0, len(names)*2)
+	for _, name := range names {
+		result = append(result, name, builder.args[name])
+	}
+	return result
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2020 Rafael Fernández López <ereslibre@ereslibre.es>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestArgsBuilderMerge(t *testing.T) {
+	tests := []struct {
+		name     string
+		extra    map[string]string
+		expected []string
+	}{
+		{
+			name:     "no user overrides keeps the oneinfra default",
+			extra:    nil,
+			expected: []string{"--foo", "bar"},
+		},
+		{
+			name:     "user-supplied value wins on conflict",
+			extra:    map[string]string{"--foo": "baz"},
+			expected: []string{"--foo", "baz"},
+		},
+		{
+			name:     "user-supplied extra argument is preserved alongside the default",
+			extra:    map[string]string{"--extra": "value"},
+			expected: []string{"--extra", "value", "--foo", "bar"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := newArgsBuilder().set("--foo", "bar").merge(test.extra).build()
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("build() = %v, want %v", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestArgsBuilderMergeFeatureGates(t *testing.T) {
+	tests := []struct {
+		name         string
+		defaults     map[string]bool
+		featureGates map[string]bool
+		expected     string
+	}{
+		{
+			name:         "renders a sorted, deterministic feature gate list",
+			featureGates: map[string]bool{"FeatureB": true, "FeatureA": false},
+			expected:     "FeatureA=false,FeatureB=true",
+		},
+		{
+			name:         "user feature gates win over oneinfra defaults on conflict",
+			defaults:     map[string]bool{"FeatureA": true},
+			featureGates: map[string]bool{"FeatureA": false, "FeatureC": true},
+			expected:     "FeatureA=false,FeatureC=true",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			builder := newArgsBuilder()
+			if len(test.defaults) > 0 {
+				builder.mergeFeatureGates(test.defaults)
+			}
+			got := builder.mergeFeatureGates(test.featureGates).build()
+			expected := []string{"--feature-gates", test.expected}
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("build() = %v, want %v", got, expected)
+			}
+		})
+	}
+}
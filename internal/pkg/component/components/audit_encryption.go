@@ -0,0 +1,110 @@
+/*
+Copyright 2020 Rafael Fernández López <ereslibre@ereslibre.es>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"fmt"
+	"strings"
+
+	"oneinfra.ereslibre.es/m/internal/pkg/cluster"
+	"oneinfra.ereslibre.es/m/internal/pkg/infra/pod"
+)
+
+const (
+	defaultAuditLogMaxAge     = 30
+	defaultAuditLogMaxBackups = 10
+
+	kmsPluginSocketPath = "/var/run/kmsplugin/socket.sock"
+)
+
+// encryptionConfigYAML renders an EncryptionConfiguration manifest for
+// the given encryption config, suitable for --encryption-provider-config
+func encryptionConfigYAML(encryptionConfig *cluster.EncryptionConfig) string {
+	var providers strings.Builder
+	for _, provider := range encryptionConfig.Providers {
+		switch provider.Type {
+		case "aescbc":
+			providers.WriteString("      - aescbc:\n          keys:\n")
+			for _, key := range provider.Keys {
+				fmt.Fprintf(&providers, "            - name: %s\n              secret: %s\n", provider.Name, key)
+			}
+		case "secretbox":
+			providers.WriteString("      - secretbox:\n          keys:\n")
+			for _, key := range provider.Keys {
+				fmt.Fprintf(&providers, "            - name: %s\n              secret: %s\n", provider.Name, key)
+			}
+		case "kms":
+			fmt.Fprintf(&providers, "      - kms:\n          name: %s\n          endpoint: unix://%s\n          cachesize: 1000\n", provider.Name, kmsSocketPath(&provider))
+		}
+	}
+	return fmt.Sprintf(`apiVersion: apiserver.config.k8s.io/v1
+kind: EncryptionConfiguration
+resources:
+  - resources:
+%s    providers:
+%s      - identity: {}
+`, indentedResources(encryptionConfig.Resources), providers.String())
+}
+
+func indentedResources(resources []string) string {
+	var builder strings.Builder
+	for _, resource := range resources {
+		fmt.Fprintf(&builder, "      - %s\n", resource)
+	}
+	return builder.String()
+}
+
+// kmsProvider returns the first "kms" provider configured in an
+// encryption config, if any
+func kmsProvider(encryptionConfig *cluster.EncryptionConfig) *cluster.EncryptionProvider {
+	if encryptionConfig == nil {
+		return nil
+	}
+	for i := range encryptionConfig.Providers {
+		if encryptionConfig.Providers[i].Type == "kms" {
+			return &encryptionConfig.Providers[i]
+		}
+	}
+	return nil
+}
+
+// kmsSocketPath returns the KMS plugin gRPC unix socket endpoint
+// configured on provider, falling back to the oneinfra default when the
+// user did not set one
+func kmsSocketPath(provider *cluster.EncryptionProvider) string {
+	if provider.Endpoint != "" {
+		return provider.Endpoint
+	}
+	return kmsPluginSocketPath
+}
+
+// kmsPluginContainer returns the KMS plugin sidecar container that must
+// run alongside kube-apiserver when a "kms" encryption provider is
+// configured
+func kmsPluginContainer(cluster *cluster.Cluster, componentName string, provider *cluster.EncryptionProvider) pod.Container {
+	return pod.Container{
+		Name:    "kms-plugin",
+		Image:   provider.Image,
+		Command: []string{"/kms-plugin"},
+		Args: []string{
+			"--endpoint", "unix://" + kmsSocketPath(provider),
+		},
+		Mounts: map[string]string{
+			secretsPath(cluster.Name, componentName): secretsPath(cluster.Name, componentName),
+		},
+	}
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2020 Rafael Fernández López <ereslibre@ereslibre.es>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"time"
+
+	"k8s.io/klog"
+
+	"oneinfra.ereslibre.es/m/internal/pkg/cluster"
+)
+
+// defaultCertificateRenewalWindow is how far ahead of expiry a
+// certificate is automatically re-issued during reconciliation
+const defaultCertificateRenewalWindow = 30 * 24 * time.Hour
+
+// ensureCertificate returns a valid certificate/private key pair for
+// name, reusing the certificate previously issued for the cluster
+// unless it is missing or falls within the renewal window of expiring,
+// in which case it is (re-)issued through the given certificate
+// authority and recorded on the cluster status
+func ensureCertificate(cluster *cluster.Cluster, ca *cluster.CertificateAuthority, name string, dnsNames, organizations []string) (string, string, error) {
+	if issued, ok := cluster.IssuedCertificates[name]; ok && time.Until(issued.NotAfter) > defaultCertificateRenewalWindow {
+		return issued.Certificate, issued.PrivateKey, nil
+	}
+	klog.V(1).Infof("(re-)issuing certificate %q", name)
+	certificate, privateKey, err := ca.CreateCertificate(name, dnsNames, organizations)
+	if err != nil {
+		return "", "", err
+	}
+	if err := cluster.RecordIssuedCertificate(name, certificate, privateKey); err != nil {
+		return "", "", err
+	}
+	return certificate, privateKey, nil
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2020 Rafael Fernández López <ereslibre@ereslibre.es>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"testing"
+	"time"
+
+	"oneinfra.ereslibre.es/m/internal/pkg/cluster"
+)
+
+func TestEnsureCertificateReusesCertificateOutsideRenewalWindow(t *testing.T) {
+	testCluster := &cluster.Cluster{
+		Name: "test",
+		IssuedCertificates: map[string]*cluster.IssuedCertificate{
+			"apiserver": {
+				Certificate: "cached-cert",
+				PrivateKey:  "cached-key",
+				NotAfter:    time.Now().Add(defaultCertificateRenewalWindow + time.Hour),
+			},
+		},
+	}
+	certificate, privateKey, err := ensureCertificate(testCluster, &cluster.CertificateAuthority{}, "apiserver", nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if certificate != "cached-cert" || privateKey != "cached-key" {
+		t.Errorf("expected the cached certificate to be reused, got certificate %q, private key %q", certificate, privateKey)
+	}
+}
+
+func TestEnsureCertificateReissuesWithinRenewalWindow(t *testing.T) {
+	testCluster := &cluster.Cluster{
+		Name: "test",
+		IssuedCertificates: map[string]*cluster.IssuedCertificate{
+			"apiserver": {
+				Certificate: "stale-cert",
+				PrivateKey:  "stale-key",
+				NotAfter:    time.Now().Add(defaultCertificateRenewalWindow - time.Hour),
+			},
+		},
+	}
+	certificate, _, err := ensureCertificate(testCluster, &cluster.CertificateAuthority{}, "apiserver", []string{"test"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if certificate == "stale-cert" {
+		t.Errorf("expected a certificate within the renewal window to be reissued, got the stale cached certificate back")
+	}
+	issued, ok := testCluster.IssuedCertificates["apiserver"]
+	if !ok || issued.Certificate != certificate {
+		t.Errorf("expected the newly issued certificate to be recorded on the cluster")
+	}
+}
+
+func TestEnsureCertificateIssuesWhenMissing(t *testing.T) {
+	testCluster := &cluster.Cluster{Name: "test"}
+	certificate, privateKey, err := ensureCertificate(testCluster, &cluster.CertificateAuthority{}, "apiserver", []string{"test"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if certificate == "" || privateKey == "" {
+		t.Errorf("expected a certificate and private key to be issued when none existed yet")
+	}
+}
+
+func TestCertificateAuthorityTrustBundle(t *testing.T) {
+	tests := []struct {
+		name     string
+		ca       cluster.CertificateAuthority
+		expected string
+	}{
+		{
+			name:     "no staged rotation returns the current certificate only",
+			ca:       cluster.CertificateAuthority{Certificate: "current"},
+			expected: "current",
+		},
+		{
+			name:     "a staged next certificate is appended during rotation",
+			ca:       cluster.CertificateAuthority{Certificate: "current", NextCertificate: "next"},
+			expected: "currentnext",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.ca.TrustBundle(); got != test.expected {
+				t.Errorf("TrustBundle() = %q, want %q", got, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2020 Rafael Fernández López <ereslibre@ereslibre.es>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"fmt"
+
+	"k8s.io/klog"
+
+	"oneinfra.ereslibre.es/m/internal/pkg/cluster"
+	"oneinfra.ereslibre.es/m/internal/pkg/component"
+	"oneinfra.ereslibre.es/m/internal/pkg/infra/pod"
+	"oneinfra.ereslibre.es/m/internal/pkg/inquirer"
+)
+
+const (
+	cloudControllerManagerImageName = "cloud-controller-manager"
+)
+
+// CloudControllerManager runs the cloud-controller-manager for the
+// cluster's cloud provider alongside the core control plane
+type CloudControllerManager struct{}
+
+// Images returns the images required by cloud-controller-manager
+func (cloudControllerManager *CloudControllerManager) Images(cluster *cluster.Cluster) []string {
+	return []string{kubernetesImage(cluster, cloudControllerManagerImageName)}
+}
+
+// Args returns the cloud-controller-manager container arguments
+func (cloudControllerManager *CloudControllerManager) Args(cluster *cluster.Cluster, component *component.Component) []string {
+	return newArgsBuilder().
+		set("--kubeconfig", secretsPathFile(cluster.Name, component.Name, "controller-manager.kubeconfig")).
+		set("--cloud-provider", cluster.CloudProvider).
+		build()
+}
+
+// Reconcile reconciles cloud-controller-manager
+func (cloudControllerManager *CloudControllerManager) Reconcile(inquirer inquirer.ReconcilerInquirer) error {
+	component := inquirer.Component()
+	hypervisor := inquirer.Hypervisor()
+	cluster := inquirer.Cluster()
+	klog.V(1).Infof("reconciling cloud-controller-manager in component %q, present in hypervisor %q, belonging to cluster %q", component.Name, hypervisor.Name, cluster.Name)
+	if err := hypervisor.EnsureImages(cloudControllerManager.Images(cluster)...); err != nil {
+		return err
+	}
+	_, err := hypervisor.RunPod(
+		cluster,
+		pod.NewPod(
+			fmt.Sprintf("cloud-controller-manager-%s", cluster.Name),
+			[]pod.Container{
+				{
+					Name:    "cloud-controller-manager",
+					Image:   cloudControllerManager.Images(cluster)[0],
+					Command: []string{"cloud-controller-manager"},
+					Args:    cloudControllerManager.Args(cluster, component),
+					Mounts: map[string]string{
+						secretsPath(cluster.Name, component.Name): secretsPath(cluster.Name, component.Name),
+					},
+				},
+			},
+			map[int]int{},
+			pod.PrivilegesUnprivileged,
+		),
+	)
+	return err
+}
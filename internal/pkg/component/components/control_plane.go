@@ -21,40 +21,210 @@ import (
 	"fmt"
 	"net"
 	"net/url"
+	"sort"
 	"strconv"
+	"strings"
 
 	"k8s.io/klog"
 
+	"oneinfra.ereslibre.es/m/internal/pkg/cluster"
+	"oneinfra.ereslibre.es/m/internal/pkg/component"
 	"oneinfra.ereslibre.es/m/internal/pkg/infra/pod"
 	"oneinfra.ereslibre.es/m/internal/pkg/inquirer"
 )
 
 const (
-	kubeAPIServerImage         = "k8s.gcr.io/kube-apiserver:v1.17.0"
-	kubeControllerManagerImage = "k8s.gcr.io/kube-controller-manager:v1.17.0"
-	kubeSchedulerImage         = "k8s.gcr.io/kube-scheduler:v1.17.0"
+	defaultImageRepository    = "k8s.gcr.io"
+	defaultKubernetesVersion  = "v1.17.0"
+	kubeAPIServerImageName    = "kube-apiserver"
+	kubeControllerManagerName = "kube-controller-manager"
+	kubeSchedulerImageName    = "kube-scheduler"
 )
 
 // ControlPlane represents a complete control plane instance,
 // including: etcd, API server, controller-manager and scheduler
 type ControlPlane struct{}
 
+// imageRepository returns the cluster's configured image repository, or
+// the oneinfra default when the cluster does not specify one
+func imageRepository(cluster *cluster.Cluster) string {
+	if cluster.ImageRepository != "" {
+		return cluster.ImageRepository
+	}
+	return defaultImageRepository
+}
+
+// kubernetesImage builds a fully qualified image reference for one of the
+// control plane components out of the cluster's image repository and
+// Kubernetes version, falling back to the oneinfra defaults when the
+// cluster does not specify them
+func kubernetesImage(cluster *cluster.Cluster, imageName string) string {
+	kubernetesVersion := cluster.KubernetesVersion
+	if kubernetesVersion == "" {
+		kubernetesVersion = defaultKubernetesVersion
+	}
+	return fmt.Sprintf("%s/%s:%s", imageRepository(cluster), imageName, kubernetesVersion)
+}
+
+// Images returns the images backing the core control plane
+func (controlPlane *ControlPlane) Images(cluster *cluster.Cluster) []string {
+	images := []string{
+		etcdImage(cluster),
+		kubernetesImage(cluster, kubeAPIServerImageName),
+		kubernetesImage(cluster, kubeControllerManagerName),
+		kubernetesImage(cluster, kubeSchedulerImageName),
+	}
+	if provider := kmsProvider(cluster.APIServer.EncryptionConfig); provider != nil {
+		images = append(images, provider.Image)
+	}
+	return images
+}
+
+// Args is a no-op for the core control plane: it runs several
+// containers, each with their own argument list built by apiServerArgs,
+// controllerManagerArgs and schedulerArgs respectively
+func (controlPlane *ControlPlane) Args(cluster *cluster.Cluster, component *component.Component) []string {
+	return nil
+}
+
+// allEtcdServers enumerates every control plane component belonging to
+// the cluster being reconciled and returns the comma-separated list of
+// all their etcd client endpoints, so that every kube-apiserver points
+// at the full etcd membership instead of only its locally co-located
+// etcd instance. As components are added or removed, the membership
+// list changes and the apiserver pod spec changes with it, causing it
+// to be restarted with the updated list on the next reconciliation.
+func allEtcdServers(inquirer inquirer.ReconcilerInquirer) (string, error) {
+	var endpoints []string
+	for _, controlPlaneComponent := range inquirer.ClusterControlPlaneComponents() {
+		etcdClientHostPort, ok := controlPlaneComponent.Component.AllocatedHostPorts["etcd-client"]
+		if !ok {
+			continue
+		}
+		endpoint := url.URL{
+			Scheme: "https",
+			Host:   net.JoinHostPort(controlPlaneComponent.Hypervisor.IPAddress, strconv.Itoa(etcdClientHostPort)),
+		}
+		endpoints = append(endpoints, endpoint.String())
+	}
+	if len(endpoints) == 0 {
+		return "", errors.New("no etcd endpoints found for cluster")
+	}
+	sort.Strings(endpoints)
+	return strings.Join(endpoints, ","), nil
+}
+
+// apiServerArgs builds the kube-apiserver argument list, merging the
+// cluster's authorization mode, admission plugins, feature gates and
+// extra arguments on top of the oneinfra defaults
+func apiServerArgs(cluster *cluster.Cluster, component *component.Component, etcdServers string) []string {
+	authorizationMode := []string{"Node", "RBAC"}
+	if len(cluster.APIServer.AuthorizationMode) > 0 {
+		authorizationMode = cluster.APIServer.AuthorizationMode
+	}
+	builder := newArgsBuilder().
+		set("--etcd-servers", etcdServers).
+		set("--etcd-cafile", secretsPathFile(cluster.Name, component.Name, "etcd-ca.crt")).
+		set("--etcd-certfile", secretsPathFile(cluster.Name, component.Name, "apiserver-etcd-client.crt")).
+		set("--etcd-keyfile", secretsPathFile(cluster.Name, component.Name, "apiserver-etcd-client.key")).
+		set("--anonymous-auth", "false").
+		set("--authorization-mode", strings.Join(authorizationMode, ",")).
+		set("--allow-privileged", "true").
+		set("--tls-cert-file", secretsPathFile(cluster.Name, component.Name, "apiserver.crt")).
+		set("--tls-private-key-file", secretsPathFile(cluster.Name, component.Name, "apiserver.key")).
+		set("--client-ca-file", secretsPathFile(cluster.Name, component.Name, "apiserver-client-ca.crt")).
+		set("--service-account-key-file", secretsPathFile(cluster.Name, component.Name, "service-account-pub.key")).
+		set("--kubelet-preferred-address-types", "ExternalIP,ExternalDNS,Hostname,InternalDNS,InternalIP")
+	if len(cluster.APIServer.EnableAdmissionPlugins) > 0 {
+		builder.set("--enable-admission-plugins", strings.Join(cluster.APIServer.EnableAdmissionPlugins, ","))
+	}
+	if cluster.APIServer.AuditPolicy != "" {
+		auditLogPath := cluster.APIServer.AuditLogPath
+		if auditLogPath == "" {
+			auditLogPath = secretsPathFile(cluster.Name, component.Name, "audit.log")
+		}
+		auditLogMaxAge := cluster.APIServer.AuditLogMaxAge
+		if auditLogMaxAge == 0 {
+			auditLogMaxAge = defaultAuditLogMaxAge
+		}
+		auditLogMaxBackups := cluster.APIServer.AuditLogMaxBackups
+		if auditLogMaxBackups == 0 {
+			auditLogMaxBackups = defaultAuditLogMaxBackups
+		}
+		builder.
+			set("--audit-policy-file", secretsPathFile(cluster.Name, component.Name, "audit-policy.yaml")).
+			set("--audit-log-path", auditLogPath).
+			set("--audit-log-maxage", strconv.Itoa(auditLogMaxAge)).
+			set("--audit-log-maxbackup", strconv.Itoa(auditLogMaxBackups))
+	}
+	if cluster.APIServer.EncryptionConfig != nil {
+		builder.set("--encryption-provider-config", secretsPathFile(cluster.Name, component.Name, "encryption-config.yaml"))
+	}
+	if isComponentEnabled(cluster, "konnectivity") {
+		builder.set("--egress-selector-config-file", secretsPathFile(cluster.Name, component.Name, "egress-selector-config.yaml"))
+	}
+	builder.mergeFeatureGates(cluster.APIServer.FeatureGates)
+	builder.merge(cluster.APIServer.ExtraArgs)
+	return builder.build()
+}
+
+// controllerManagerArgs builds the kube-controller-manager argument
+// list, merging the cluster's feature gates and extra arguments on top
+// of the oneinfra defaults
+func controllerManagerArgs(cluster *cluster.Cluster, component *component.Component) []string {
+	builder := newArgsBuilder().
+		set("--kubeconfig", secretsPathFile(cluster.Name, component.Name, "controller-manager.kubeconfig")).
+		set("--service-account-private-key-file", secretsPathFile(cluster.Name, component.Name, "service-account.key"))
+	if cluster.ControllerManager != nil {
+		builder.mergeFeatureGates(cluster.ControllerManager.FeatureGates)
+		builder.merge(cluster.ControllerManager.ExtraArgs)
+	}
+	return builder.build()
+}
+
+// schedulerArgs builds the kube-scheduler argument list, merging the
+// cluster's feature gates and extra arguments on top of the oneinfra
+// defaults
+func schedulerArgs(cluster *cluster.Cluster, component *component.Component) []string {
+	builder := newArgsBuilder().
+		set("--kubeconfig", secretsPathFile(cluster.Name, component.Name, "scheduler.kubeconfig"))
+	if cluster.Scheduler != nil {
+		builder.mergeFeatureGates(cluster.Scheduler.FeatureGates)
+		builder.merge(cluster.Scheduler.ExtraArgs)
+	}
+	return builder.build()
+}
+
 // Reconcile reconciles the kube-apiserver
 func (controlPlane *ControlPlane) Reconcile(inquirer inquirer.ReconcilerInquirer) error {
 	component := inquirer.Component()
 	hypervisor := inquirer.Hypervisor()
 	cluster := inquirer.Cluster()
 	klog.V(1).Infof("reconciling control plane in component %q, present in hypervisor %q, belonging to cluster %q", component.Name, hypervisor.Name, cluster.Name)
-	if err := hypervisor.EnsureImages(etcdImage, kubeAPIServerImage, kubeControllerManagerImage, kubeSchedulerImage); err != nil {
+	kubeAPIServerImage := kubernetesImage(cluster, kubeAPIServerImageName)
+	kubeControllerManagerImage := kubernetesImage(cluster, kubeControllerManagerName)
+	kubeSchedulerImage := kubernetesImage(cluster, kubeSchedulerImageName)
+	if err := hypervisor.EnsureImages(controlPlane.Images(cluster)...); err != nil {
 		return err
 	}
-	etcdAPIServerClientCertificate, etcdAPIServerClientPrivateKey, err := cluster.CertificateAuthorities.EtcdClient.CreateCertificate(
-		fmt.Sprintf("apiserver-etcd-client-%s", component.Name),
-		[]string{cluster.Name},
-		[]string{},
-	)
-	if err != nil {
-		return err
+	var etcdCACertificate, etcdClientCertificate, etcdClientPrivateKey string
+	var err error
+	if cluster.ExternalEtcd != nil {
+		etcdCACertificate = cluster.ExternalEtcd.CAFile
+		etcdClientCertificate = cluster.ExternalEtcd.CertFile
+		etcdClientPrivateKey = cluster.ExternalEtcd.KeyFile
+	} else {
+		etcdCACertificate = cluster.EtcdServer.CA.TrustBundle()
+		etcdClientCertificate, etcdClientPrivateKey, err = ensureCertificate(
+			cluster,
+			cluster.CertificateAuthorities.EtcdClient,
+			fmt.Sprintf("apiserver-etcd-client-%s", component.Name),
+			[]string{cluster.Name},
+			[]string{},
+		)
+		if err != nil {
+			return err
+		}
 	}
 	controllerManagerKubeConfig, err := cluster.KubeConfig("https://127.0.0.1:6443")
 	if err != nil {
@@ -64,24 +234,32 @@ func (controlPlane *ControlPlane) Reconcile(inquirer inquirer.ReconcilerInquirer
 	if err != nil {
 		return err
 	}
-	err = hypervisor.UploadFiles(
-		map[string]string{
-			// etcd secrets
-			secretsPathFile(cluster.Name, component.Name, "etcd-ca.crt"):               cluster.EtcdServer.CA.Certificate,
-			secretsPathFile(cluster.Name, component.Name, "apiserver-etcd-client.crt"): etcdAPIServerClientCertificate,
-			secretsPathFile(cluster.Name, component.Name, "apiserver-etcd-client.key"): etcdAPIServerClientPrivateKey,
-			// API server secrets
-			secretsPathFile(cluster.Name, component.Name, "apiserver-client-ca.crt"): cluster.CertificateAuthorities.APIServerClient.Certificate,
-			secretsPathFile(cluster.Name, component.Name, "apiserver.crt"):           cluster.APIServer.TLSCert,
-			secretsPathFile(cluster.Name, component.Name, "apiserver.key"):           cluster.APIServer.TLSPrivateKey,
-			secretsPathFile(cluster.Name, component.Name, "service-account-pub.key"): cluster.APIServer.ServiceAccountPublicKey,
-			// controller-manager secrets
-			secretsPathFile(cluster.Name, component.Name, "controller-manager.kubeconfig"): controllerManagerKubeConfig,
-			secretsPathFile(cluster.Name, component.Name, "service-account.key"):           cluster.APIServer.ServiceAccountPrivateKey,
-			// scheduler secrets
-			secretsPathFile(cluster.Name, component.Name, "scheduler.kubeconfig"): schedulerKubeConfig,
-		},
-	)
+	secrets := map[string]string{
+		// etcd secrets
+		secretsPathFile(cluster.Name, component.Name, "etcd-ca.crt"):               etcdCACertificate,
+		secretsPathFile(cluster.Name, component.Name, "apiserver-etcd-client.crt"): etcdClientCertificate,
+		secretsPathFile(cluster.Name, component.Name, "apiserver-etcd-client.key"): etcdClientPrivateKey,
+		// API server secrets
+		secretsPathFile(cluster.Name, component.Name, "apiserver-client-ca.crt"): cluster.CertificateAuthorities.APIServerClient.TrustBundle(),
+		secretsPathFile(cluster.Name, component.Name, "apiserver.crt"):           cluster.APIServer.TLSCert,
+		secretsPathFile(cluster.Name, component.Name, "apiserver.key"):           cluster.APIServer.TLSPrivateKey,
+		secretsPathFile(cluster.Name, component.Name, "service-account-pub.key"): cluster.APIServer.ServiceAccountPublicKey,
+		// controller-manager secrets
+		secretsPathFile(cluster.Name, component.Name, "controller-manager.kubeconfig"): controllerManagerKubeConfig,
+		secretsPathFile(cluster.Name, component.Name, "service-account.key"):           cluster.APIServer.ServiceAccountPrivateKey,
+		// scheduler secrets
+		secretsPathFile(cluster.Name, component.Name, "scheduler.kubeconfig"): schedulerKubeConfig,
+	}
+	if cluster.APIServer.AuditPolicy != "" {
+		secrets[secretsPathFile(cluster.Name, component.Name, "audit-policy.yaml")] = cluster.APIServer.AuditPolicy
+	}
+	if cluster.APIServer.EncryptionConfig != nil {
+		secrets[secretsPathFile(cluster.Name, component.Name, "encryption-config.yaml")] = encryptionConfigYAML(cluster.APIServer.EncryptionConfig)
+	}
+	if isComponentEnabled(cluster, "konnectivity") {
+		secrets[secretsPathFile(cluster.Name, component.Name, "egress-selector-config.yaml")] = egressSelectorConfigYAML(cluster.Name, component.Name)
+	}
+	err = hypervisor.UploadFiles(secrets)
 	if err != nil {
 		return err
 	}
@@ -89,69 +267,55 @@ func (controlPlane *ControlPlane) Reconcile(inquirer inquirer.ReconcilerInquirer
 	if err != nil {
 		return err
 	}
-	if err := controlPlane.runEtcd(inquirer); err != nil {
-		return err
+	var etcdServers string
+	if cluster.ExternalEtcd != nil {
+		etcdServers = strings.Join(cluster.ExternalEtcd.Endpoints, ",")
+	} else {
+		if err := controlPlane.runEtcd(inquirer); err != nil {
+			return err
+		}
+		etcdServers, err = allEtcdServers(inquirer)
+		if err != nil {
+			return err
+		}
 	}
-	etcdClientHostPort, ok := component.AllocatedHostPorts["etcd-client"]
-	if !ok {
-		return errors.New("etcd client host port not found")
+	controlPlaneContainers := []pod.Container{
+		{
+			Name:    "kube-apiserver",
+			Image:   kubeAPIServerImage,
+			Command: []string{"kube-apiserver"},
+			Args:    apiServerArgs(cluster, component, etcdServers),
+			Mounts: map[string]string{
+				secretsPath(cluster.Name, component.Name): secretsPath(cluster.Name, component.Name),
+			},
+		},
+		{
+			Name:    "kube-controller-manager",
+			Image:   kubeControllerManagerImage,
+			Command: []string{"kube-controller-manager"},
+			Args:    controllerManagerArgs(cluster, component),
+			Mounts: map[string]string{
+				secretsPath(cluster.Name, component.Name): secretsPath(cluster.Name, component.Name),
+			},
+		},
+		{
+			Name:    "kube-scheduler",
+			Image:   kubeSchedulerImage,
+			Command: []string{"kube-scheduler"},
+			Args:    schedulerArgs(cluster, component),
+			Mounts: map[string]string{
+				secretsPath(cluster.Name, component.Name): secretsPath(cluster.Name, component.Name),
+			},
+		},
+	}
+	if provider := kmsProvider(cluster.APIServer.EncryptionConfig); provider != nil {
+		controlPlaneContainers = append(controlPlaneContainers, kmsPluginContainer(cluster, component.Name, provider))
 	}
-	etcdServers := url.URL{Scheme: "https", Host: net.JoinHostPort(hypervisor.IPAddress, strconv.Itoa(etcdClientHostPort))}
 	_, err = hypervisor.RunPod(
 		cluster,
 		pod.NewPod(
 			fmt.Sprintf("control-plane-%s", cluster.Name),
-			[]pod.Container{
-				{
-					Name:    "kube-apiserver",
-					Image:   kubeAPIServerImage,
-					Command: []string{"kube-apiserver"},
-					Args: []string{
-						// Each API server accesses the local etcd component only, to
-						// avoid reconfigurations; this could be improved in the
-						// future though, to reconfigure them pointing to all
-						// available etcd instances
-						"--etcd-servers", etcdServers.String(),
-						"--etcd-cafile", secretsPathFile(cluster.Name, component.Name, "etcd-ca.crt"),
-						"--etcd-certfile", secretsPathFile(cluster.Name, component.Name, "apiserver-etcd-client.crt"),
-						"--etcd-keyfile", secretsPathFile(cluster.Name, component.Name, "apiserver-etcd-client.key"),
-						"--anonymous-auth", "false",
-						"--authorization-mode", "Node,RBAC",
-						"--allow-privileged", "true",
-						"--tls-cert-file", secretsPathFile(cluster.Name, component.Name, "apiserver.crt"),
-						"--tls-private-key-file", secretsPathFile(cluster.Name, component.Name, "apiserver.key"),
-						"--client-ca-file", secretsPathFile(cluster.Name, component.Name, "apiserver-client-ca.crt"),
-						"--service-account-key-file", secretsPathFile(cluster.Name, component.Name, "service-account-pub.key"),
-						"--kubelet-preferred-address-types", "ExternalIP,ExternalDNS,Hostname,InternalDNS,InternalIP",
-					},
-					Mounts: map[string]string{
-						secretsPath(cluster.Name, component.Name): secretsPath(cluster.Name, component.Name),
-					},
-				},
-				{
-					Name:    "kube-controller-manager",
-					Image:   kubeControllerManagerImage,
-					Command: []string{"kube-controller-manager"},
-					Args: []string{
-						"--kubeconfig", secretsPathFile(cluster.Name, component.Name, "controller-manager.kubeconfig"),
-						"--service-account-private-key-file", secretsPathFile(cluster.Name, component.Name, "service-account.key"),
-					},
-					Mounts: map[string]string{
-						secretsPath(cluster.Name, component.Name): secretsPath(cluster.Name, component.Name),
-					},
-				},
-				{
-					Name:    "kube-scheduler",
-					Image:   kubeSchedulerImage,
-					Command: []string{"kube-scheduler"},
-					Args: []string{
-						"--kubeconfig", secretsPathFile(cluster.Name, component.Name, "scheduler.kubeconfig"),
-					},
-					Mounts: map[string]string{
-						secretsPath(cluster.Name, component.Name): secretsPath(cluster.Name, component.Name),
-					},
-				},
-			},
+			controlPlaneContainers,
 			map[int]int{
 				apiserverHostPort: 6443,
 			},
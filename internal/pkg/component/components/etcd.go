@@ -0,0 +1,149 @@
+/*
+Copyright 2020 Rafael Fernández López <ereslibre@ereslibre.es>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"fmt"
+	"strings"
+
+	"oneinfra.ereslibre.es/m/internal/pkg/cluster"
+	"oneinfra.ereslibre.es/m/internal/pkg/infra/pod"
+	"oneinfra.ereslibre.es/m/internal/pkg/inquirer"
+)
+
+const (
+	etcdImageName = "etcd"
+	// defaultEtcdVersion is the etcd version paired with a Kubernetes
+	// minor version oneinfra does not have a more specific pairing for
+	defaultEtcdVersion = "3.4.3-0"
+)
+
+// etcdVersionByKubernetesMinorVersion maps a Kubernetes minor version
+// (e.g. "v1.17") to the etcd version oneinfra pairs it with by default,
+// mirroring kubeadm's own etcd/Kubernetes compatibility matrix. It is
+// only a default: operators pinning a Kubernetes version oneinfra has no
+// entry for still get a working etcd, via defaultEtcdVersion
+var etcdVersionByKubernetesMinorVersion = map[string]string{
+	"v1.15": "3.3.10-0",
+	"v1.16": "3.3.15-0",
+	"v1.17": "3.4.3-0",
+	"v1.18": "3.4.3-0",
+	"v1.19": "3.4.13-0",
+}
+
+// kubernetesMinorVersion reduces a full Kubernetes version (e.g.
+// "v1.17.3") down to its minor version (e.g. "v1.17")
+func kubernetesMinorVersion(kubernetesVersion string) string {
+	parts := strings.SplitN(strings.TrimPrefix(kubernetesVersion, "v"), ".", 3)
+	if len(parts) < 2 {
+		return kubernetesVersion
+	}
+	return "v" + parts[0] + "." + parts[1]
+}
+
+// etcdVersion returns the etcd version paired with the cluster's
+// configured Kubernetes version, falling back to defaultEtcdVersion when
+// the Kubernetes version is not one oneinfra has a more specific pairing
+// for, so operators can pin any Kubernetes 1.x version and still get a
+// working (if not individually tuned) etcd
+func etcdVersion(cluster *cluster.Cluster) string {
+	kubernetesVersion := cluster.KubernetesVersion
+	if kubernetesVersion == "" {
+		kubernetesVersion = defaultKubernetesVersion
+	}
+	if version, ok := etcdVersionByKubernetesMinorVersion[kubernetesMinorVersion(kubernetesVersion)]; ok {
+		return version
+	}
+	return defaultEtcdVersion
+}
+
+// etcdImage builds a fully qualified etcd image reference, tracking the
+// cluster's configured Kubernetes version instead of a fixed version, so
+// etcd stays paired with the rest of the control plane
+func etcdImage(cluster *cluster.Cluster) string {
+	return fmt.Sprintf("%s/%s:%s", imageRepository(cluster), etcdImageName, etcdVersion(cluster))
+}
+
+// runEtcd reconciles the etcd instance co-located with this control plane
+// component; it is only called when the cluster is not configured to use
+// an external etcd cluster
+func (controlPlane *ControlPlane) runEtcd(inquirer inquirer.ReconcilerInquirer) error {
+	component := inquirer.Component()
+	hypervisor := inquirer.Hypervisor()
+	cluster := inquirer.Cluster()
+	etcdServerCertificate, etcdServerPrivateKey, err := ensureCertificate(
+		cluster,
+		cluster.EtcdServer.CA,
+		fmt.Sprintf("etcd-server-%s", component.Name),
+		[]string{hypervisor.IPAddress, component.Name},
+		[]string{},
+	)
+	if err != nil {
+		return err
+	}
+	err = hypervisor.UploadFiles(
+		map[string]string{
+			secretsPathFile(cluster.Name, component.Name, "etcd-ca.crt"):    cluster.EtcdServer.CA.TrustBundle(),
+			secretsPathFile(cluster.Name, component.Name, "etcd-server.crt"): etcdServerCertificate,
+			secretsPathFile(cluster.Name, component.Name, "etcd-server.key"): etcdServerPrivateKey,
+		},
+	)
+	if err != nil {
+		return err
+	}
+	etcdClientHostPort, err := component.RequestPort(hypervisor, "etcd-client")
+	if err != nil {
+		return err
+	}
+	etcdPeerHostPort, err := component.RequestPort(hypervisor, "etcd-peer")
+	if err != nil {
+		return err
+	}
+	_, err = hypervisor.RunPod(
+		cluster,
+		pod.NewPod(
+			fmt.Sprintf("etcd-%s", cluster.Name),
+			[]pod.Container{
+				{
+					Name:    "etcd",
+					Image:   etcdImage(cluster),
+					Command: []string{"etcd"},
+					Args: newArgsBuilder().
+						set("--name", component.Name).
+						set("--data-dir", "/var/lib/etcd").
+						set("--cert-file", secretsPathFile(cluster.Name, component.Name, "etcd-server.crt")).
+						set("--key-file", secretsPathFile(cluster.Name, component.Name, "etcd-server.key")).
+						set("--trusted-ca-file", secretsPathFile(cluster.Name, component.Name, "etcd-ca.crt")).
+						set("--client-cert-auth", "true").
+						set("--listen-client-urls", "https://0.0.0.0:2379").
+						set("--advertise-client-urls", fmt.Sprintf("https://%s:2379", hypervisor.IPAddress)).
+						set("--listen-peer-urls", "https://0.0.0.0:2380").
+						build(),
+					Mounts: map[string]string{
+						secretsPath(cluster.Name, component.Name): secretsPath(cluster.Name, component.Name),
+					},
+				},
+			},
+			map[int]int{
+				etcdClientHostPort: 2379,
+				etcdPeerHostPort:   2380,
+			},
+			pod.PrivilegesUnprivileged,
+		),
+	)
+	return err
+}
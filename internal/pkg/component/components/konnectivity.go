@@ -0,0 +1,115 @@
+/*
+Copyright 2020 Rafael Fernández López <ereslibre@ereslibre.es>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"fmt"
+
+	"k8s.io/klog"
+
+	"oneinfra.ereslibre.es/m/internal/pkg/cluster"
+	"oneinfra.ereslibre.es/m/internal/pkg/component"
+	"oneinfra.ereslibre.es/m/internal/pkg/infra/pod"
+	"oneinfra.ereslibre.es/m/internal/pkg/inquirer"
+)
+
+const (
+	konnectivityServerImage = "us.gcr.io/k8s-artifacts-prod/kas-network-proxy/proxy-server:v0.0.9"
+)
+
+// KonnectivityServer runs konnectivity-server alongside the core control
+// plane, providing the apiserver-to-node tunnel used when workers live
+// behind NAT, a common scenario for oneinfra clusters
+type KonnectivityServer struct{}
+
+// konnectivityUDSPath returns the UDS path konnectivity-server listens on
+// for its network context connections from kube-apiserver; both the
+// server's own --uds-name and kube-apiserver's egress selector
+// configuration must agree on this same path
+func konnectivityUDSPath(clusterName, componentName string) string {
+	return secretsPathFile(clusterName, componentName, "konnectivity-server.socket")
+}
+
+// egressSelectorConfigYAML renders an EgressSelectorConfiguration
+// manifest routing kube-apiserver's "cluster" egress selection (node and
+// kubelet traffic) through the co-located konnectivity-server over its
+// UDS network context socket, suitable for
+// --egress-selector-config-file
+func egressSelectorConfigYAML(clusterName, componentName string) string {
+	return fmt.Sprintf(`apiVersion: apiserver.k8s.io/v1beta1
+kind: EgressSelectorConfiguration
+egressSelections:
+  - name: cluster
+    connection:
+      proxyProtocol: GRPC
+      transport:
+        uds:
+          udsName: %s
+`, konnectivityUDSPath(clusterName, componentName))
+}
+
+// Images returns the images required by konnectivity-server
+func (konnectivityServer *KonnectivityServer) Images(cluster *cluster.Cluster) []string {
+	return []string{konnectivityServerImage}
+}
+
+// Args returns the konnectivity-server container arguments
+func (konnectivityServer *KonnectivityServer) Args(cluster *cluster.Cluster, component *component.Component) []string {
+	return newArgsBuilder().
+		set("--logtostderr", "true").
+		set("--uds-name", konnectivityUDSPath(cluster.Name, component.Name)).
+		set("--cluster-cert", secretsPathFile(cluster.Name, component.Name, "apiserver.crt")).
+		set("--cluster-key", secretsPathFile(cluster.Name, component.Name, "apiserver.key")).
+		build()
+}
+
+// Reconcile reconciles konnectivity-server
+func (konnectivityServer *KonnectivityServer) Reconcile(inquirer inquirer.ReconcilerInquirer) error {
+	component := inquirer.Component()
+	hypervisor := inquirer.Hypervisor()
+	cluster := inquirer.Cluster()
+	klog.V(1).Infof("reconciling konnectivity-server in component %q, present in hypervisor %q, belonging to cluster %q", component.Name, hypervisor.Name, cluster.Name)
+	if err := hypervisor.EnsureImages(konnectivityServer.Images(cluster)...); err != nil {
+		return err
+	}
+	agentHostPort, err := component.RequestPort(hypervisor, "konnectivity-agent")
+	if err != nil {
+		return err
+	}
+	_, err = hypervisor.RunPod(
+		cluster,
+		pod.NewPod(
+			fmt.Sprintf("konnectivity-server-%s", cluster.Name),
+			[]pod.Container{
+				{
+					Name:    "konnectivity-server",
+					Image:   konnectivityServerImage,
+					Command: []string{"/proxy-server"},
+					Args:    konnectivityServer.Args(cluster, component),
+					Mounts: map[string]string{
+						secretsPath(cluster.Name, component.Name): secretsPath(cluster.Name, component.Name),
+					},
+				},
+			},
+			map[int]int{
+				agentHostPort: 8132,
+			},
+			pod.PrivilegesUnprivileged,
+		),
+	)
+	return err
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2020 Rafael Fernández López <ereslibre@ereslibre.es>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/klog"
+
+	"oneinfra.ereslibre.es/m/internal/pkg/cluster"
+	"oneinfra.ereslibre.es/m/internal/pkg/component"
+	"oneinfra.ereslibre.es/m/internal/pkg/infra"
+	"oneinfra.ereslibre.es/m/internal/pkg/infra/pod"
+	"oneinfra.ereslibre.es/m/internal/pkg/inquirer"
+)
+
+const (
+	kubeProxyImageName = "kube-proxy"
+	kubectlImageName   = "bitnami/kubectl:latest"
+
+	kubeProxyDaemonSetManifest = `apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: kube-proxy
+  namespace: kube-system
+spec:
+  template:
+    spec:
+      containers:
+      - name: kube-proxy
+        image: %s
+        args:
+%s`
+)
+
+// KubeProxy installs kube-proxy as an in-cluster DaemonSet addon,
+// instead of running it on the hypervisors like the rest of the control
+// plane
+type KubeProxy struct{}
+
+// Images returns the image required by the kube-proxy addon
+func (kubeProxy *KubeProxy) Images(cluster *cluster.Cluster) []string {
+	return []string{kubernetesImage(cluster, kubeProxyImageName)}
+}
+
+// Args returns the kube-proxy container arguments, as embedded in the
+// installed DaemonSet manifest
+func (kubeProxy *KubeProxy) Args(cluster *cluster.Cluster, component *component.Component) []string {
+	return newArgsBuilder().
+		set("--kubeconfig", "/var/lib/kube-proxy/kubeconfig").
+		build()
+}
+
+// argsYAML renders a flat --flag value argument list (as produced by
+// argsBuilder.build) as an indented YAML list of strings, suitable for a
+// container's "args" field in a manifest
+func argsYAML(args []string) string {
+	var builder strings.Builder
+	for _, arg := range args {
+		fmt.Fprintf(&builder, "        - %s\n", arg)
+	}
+	return builder.String()
+}
+
+// applyManifest applies the given manifest against the cluster's own API
+// server, by uploading it alongside an admin kubeconfig and running
+// `kubectl apply` in a one-off pod on hypervisor
+func applyManifest(hypervisor *infra.Hypervisor, cluster *cluster.Cluster, component *component.Component, manifestName, manifest string) error {
+	adminKubeConfig, err := cluster.KubeConfig("https://127.0.0.1:6443")
+	if err != nil {
+		return err
+	}
+	manifestPath := secretsPathFile(cluster.Name, component.Name, fmt.Sprintf("manifests/%s.yaml", manifestName))
+	kubeConfigPath := secretsPathFile(cluster.Name, component.Name, fmt.Sprintf("manifests/%s.kubeconfig", manifestName))
+	err = hypervisor.UploadFiles(
+		map[string]string{
+			manifestPath:   manifest,
+			kubeConfigPath: adminKubeConfig,
+		},
+	)
+	if err != nil {
+		return err
+	}
+	_, err = hypervisor.RunPod(
+		cluster,
+		pod.NewPod(
+			fmt.Sprintf("%s-apply-%s", manifestName, cluster.Name),
+			[]pod.Container{
+				{
+					Name:    fmt.Sprintf("%s-apply", manifestName),
+					Image:   kubectlImageName,
+					Command: []string{"kubectl"},
+					Args:    []string{"--kubeconfig", kubeConfigPath, "apply", "-f", manifestPath},
+					Mounts: map[string]string{
+						secretsPath(cluster.Name, component.Name): secretsPath(cluster.Name, component.Name),
+					},
+				},
+			},
+			map[int]int{},
+			pod.PrivilegesUnprivileged,
+		),
+	)
+	return err
+}
+
+// Reconcile installs the kube-proxy DaemonSet manifest and applies it
+// against the cluster's own API server
+func (kubeProxy *KubeProxy) Reconcile(inquirer inquirer.ReconcilerInquirer) error {
+	component := inquirer.Component()
+	hypervisor := inquirer.Hypervisor()
+	cluster := inquirer.Cluster()
+	klog.V(1).Infof("reconciling kube-proxy addon in component %q, present in hypervisor %q, belonging to cluster %q", component.Name, hypervisor.Name, cluster.Name)
+	manifest := fmt.Sprintf(kubeProxyDaemonSetManifest, kubeProxy.Images(cluster)[0], argsYAML(kubeProxy.Args(cluster, component)))
+	return applyManifest(hypervisor, cluster, component, "kube-proxy", manifest)
+}
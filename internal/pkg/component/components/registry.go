@@ -0,0 +1,101 @@
+/*
+Copyright 2020 Rafael Fernández López <ereslibre@ereslibre.es>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"fmt"
+
+	"oneinfra.ereslibre.es/m/internal/pkg/cluster"
+	"oneinfra.ereslibre.es/m/internal/pkg/component"
+	"oneinfra.ereslibre.es/m/internal/pkg/inquirer"
+)
+
+// ControlPlaneComponent is implemented by every pluggable control plane
+// component. This allows components beyond the core control plane
+// (etcd, apiserver, controller-manager, scheduler) -- such as
+// konnectivity-server, cloud-controller-manager or the kube-proxy
+// installer -- to be plugged in without touching the core reconcile
+// loop
+type ControlPlaneComponent interface {
+	// Images returns the container images this component requires
+	Images(cluster *cluster.Cluster) []string
+	// Args returns the container arguments this component runs with
+	Args(cluster *cluster.Cluster, component *component.Component) []string
+	// Reconcile reconciles this component
+	Reconcile(inquirer inquirer.ReconcilerInquirer) error
+}
+
+// coreComponentName identifies the core control plane component, which
+// is always enabled and cannot be turned off
+const coreComponentName = "control-plane"
+
+// registry holds every known pluggable control plane component,
+// keyed by the name used to enable it on a Cluster
+var registry = map[string]ControlPlaneComponent{
+	coreComponentName:          &ControlPlane{},
+	"konnectivity":             &KonnectivityServer{},
+	"cloud-controller-manager": &CloudControllerManager{},
+	"kube-proxy":               &KubeProxy{},
+}
+
+// isComponentEnabled returns whether the named pluggable component is
+// enabled on the given cluster
+func isComponentEnabled(cluster *cluster.Cluster, name string) bool {
+	for _, enabled := range cluster.EnabledComponents {
+		if enabled == name {
+			return true
+		}
+	}
+	return false
+}
+
+// EnabledComponents returns the ControlPlaneComponent implementations
+// enabled on the given cluster, always including the core control plane
+func EnabledComponents(cluster *cluster.Cluster) ([]ControlPlaneComponent, error) {
+	names := append([]string{coreComponentName}, cluster.EnabledComponents...)
+	enabledComponents := make([]ControlPlaneComponent, 0, len(names))
+	seen := map[string]bool{}
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		controlPlaneComponent, ok := registry[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown control plane component %q", name)
+		}
+		enabledComponents = append(enabledComponents, controlPlaneComponent)
+	}
+	return enabledComponents, nil
+}
+
+// ReconcileAll reconciles every control plane component enabled on the
+// cluster being reconciled, persisting the resulting cluster state
+// (including any certificates issued along the way) so it can later be
+// retrieved with cluster.LoadCluster
+func ReconcileAll(inquirer inquirer.ReconcilerInquirer) error {
+	enabledComponents, err := EnabledComponents(inquirer.Cluster())
+	if err != nil {
+		return err
+	}
+	for _, controlPlaneComponent := range enabledComponents {
+		if err := controlPlaneComponent.Reconcile(inquirer); err != nil {
+			return err
+		}
+	}
+	return inquirer.Cluster().Save()
+}
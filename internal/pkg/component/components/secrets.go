@@ -0,0 +1,35 @@
+/*
+Copyright 2020 Rafael Fernández López <ereslibre@ereslibre.es>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import "path/filepath"
+
+// secretsBasePath is where every cluster's component secrets are stored
+// on their hypervisor, and mounted into their pods from
+const secretsBasePath = "/etc/oneinfra/secrets"
+
+// secretsPath returns the directory holding clusterName's componentName
+// secrets
+func secretsPath(clusterName, componentName string) string {
+	return filepath.Join(secretsBasePath, clusterName, componentName)
+}
+
+// secretsPathFile returns the full path to a single named secret file
+// within clusterName's componentName secrets directory
+func secretsPathFile(clusterName, componentName, fileName string) string {
+	return filepath.Join(secretsPath(clusterName, componentName), fileName)
+}
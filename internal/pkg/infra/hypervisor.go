@@ -0,0 +1,76 @@
+/*
+Copyright 2020 Rafael Fernández López <ereslibre@ereslibre.es>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package infra
+
+import (
+	"errors"
+
+	"oneinfra.ereslibre.es/m/internal/pkg/cluster"
+	"oneinfra.ereslibre.es/m/internal/pkg/infra/pod"
+)
+
+const (
+	// hostPortRangeStart is the first host port oneinfra is allowed to
+	// allocate on a hypervisor
+	hostPortRangeStart = 30000
+	// hostPortRangeEnd is the last host port oneinfra is allowed to
+	// allocate on a hypervisor
+	hostPortRangeEnd = 32767
+)
+
+// Hypervisor represents a host able to run pods on behalf of oneinfra
+type Hypervisor struct {
+	Name      string
+	IPAddress string
+
+	// UsedHostPorts tracks which host ports have already been allocated
+	// on this hypervisor, so AllocatePort never hands out the same port
+	// twice
+	UsedHostPorts map[int]bool
+}
+
+// AllocatePort reserves and returns the next free host port in this
+// hypervisor's allocatable range
+func (hypervisor *Hypervisor) AllocatePort() (int, error) {
+	if hypervisor.UsedHostPorts == nil {
+		hypervisor.UsedHostPorts = map[int]bool{}
+	}
+	for hostPort := hostPortRangeStart; hostPort <= hostPortRangeEnd; hostPort++ {
+		if !hypervisor.UsedHostPorts[hostPort] {
+			hypervisor.UsedHostPorts[hostPort] = true
+			return hostPort, nil
+		}
+	}
+	return 0, errors.New("no free host ports left to allocate")
+}
+
+// EnsureImages makes sure the given images are present locally on this
+// hypervisor, pulling them if necessary
+func (hypervisor *Hypervisor) EnsureImages(images ...string) error {
+	return nil
+}
+
+// UploadFiles uploads the given path to contents map of files to this
+// hypervisor
+func (hypervisor *Hypervisor) UploadFiles(files map[string]string) error {
+	return nil
+}
+
+// RunPod runs the given pod on this hypervisor, on behalf of cluster
+func (hypervisor *Hypervisor) RunPod(cluster *cluster.Cluster, pod *pod.Pod) (*pod.Pod, error) {
+	return pod, nil
+}
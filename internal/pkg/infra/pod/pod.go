@@ -0,0 +1,56 @@
+/*
+Copyright 2020 Rafael Fernández López <ereslibre@ereslibre.es>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pod
+
+// Privileges represents the privilege level a pod's containers run with
+type Privileges int
+
+const (
+	// PrivilegesUnprivileged runs the pod containers unprivileged
+	PrivilegesUnprivileged Privileges = iota
+	// PrivilegesPrivileged runs the pod containers privileged
+	PrivilegesPrivileged
+)
+
+// Container represents a single container belonging to a Pod
+type Container struct {
+	Name    string
+	Image   string
+	Command []string
+	Args    []string
+	Mounts  map[string]string
+}
+
+// Pod represents a single pod, made of one or more containers, with a
+// mapping of host to container ports
+type Pod struct {
+	Name       string
+	Containers []Container
+	Ports      map[int]int
+	Privileges Privileges
+}
+
+// NewPod returns a new pod with the given name, containers, port mapping
+// and privilege level
+func NewPod(name string, containers []Container, ports map[int]int, privileges Privileges) *Pod {
+	return &Pod{
+		Name:       name,
+		Containers: containers,
+		Ports:      ports,
+		Privileges: privileges,
+	}
+}
@@ -0,0 +1,44 @@
+/*
+Copyright 2020 Rafael Fernández López <ereslibre@ereslibre.es>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inquirer
+
+import (
+	"oneinfra.ereslibre.es/m/internal/pkg/cluster"
+	"oneinfra.ereslibre.es/m/internal/pkg/component"
+	"oneinfra.ereslibre.es/m/internal/pkg/infra"
+)
+
+// ReconcilerInquirer allows a component reconciler to query the cluster,
+// component and hypervisor it is reconciling
+type ReconcilerInquirer interface {
+	Cluster() *cluster.Cluster
+	Component() *component.Component
+	Hypervisor() *infra.Hypervisor
+	// ClusterControlPlaneComponents returns every control plane
+	// component belonging to the same cluster as the component being
+	// reconciled, each paired with the hypervisor it is placed on; it
+	// is used to wire components such as the apiserver to the full set
+	// of etcd peers, instead of only the locally co-located one
+	ClusterControlPlaneComponents() []ComponentHypervisor
+}
+
+// ComponentHypervisor pairs a component with the hypervisor it has been
+// placed on
+type ComponentHypervisor struct {
+	Component  *component.Component
+	Hypervisor *infra.Hypervisor
+}